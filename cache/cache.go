@@ -0,0 +1,140 @@
+// Package cache provides a persistent, on-disk cache of favicon fetches so
+// repeated scans of the same target lists skip redundant network requests.
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Entry is a single cached favicon fetch, keyed by its final URL.
+type Entry struct {
+	URL          string
+	Body         []byte
+	ETag         string
+	LastModified string
+	ContentType  string
+	Murmur       int32
+	MD5          string
+	SHA256       string
+	FetchedAt    time.Time
+}
+
+// Cache is an in-memory store of favicon fetches backed by a single gob
+// file on disk, optionally zstd-compressed.
+type Cache struct {
+	mu       sync.RWMutex
+	path     string
+	ttl      time.Duration
+	compress bool
+	entries  map[string]Entry
+}
+
+// New returns a Cache backed by path. Entries older than ttl are treated as
+// misses; a zero ttl means entries never expire. When compress is true, the
+// backing file is zstd-compressed.
+func New(path string, ttl time.Duration, compress bool) *Cache {
+	return &Cache{
+		path:     path,
+		ttl:      ttl,
+		compress: compress,
+		entries:  make(map[string]Entry),
+	}
+}
+
+// Get returns the cached entry for url, if present and not expired.
+func (c *Cache) Get(url string) (Entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[url]
+	if !ok {
+		return Entry{}, false
+	}
+	if c.ttl > 0 && time.Since(entry.FetchedAt) > c.ttl {
+		return Entry{}, false
+	}
+
+	return entry, true
+}
+
+// Set stores (or replaces) the cached entry for url, stamping FetchedAt.
+func (c *Cache) Set(url string, entry Entry) {
+	entry.URL = url
+	entry.FetchedAt = time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[url] = entry
+}
+
+// Import loads entries from the cache's backing file. A missing file is not
+// an error; the cache simply starts out empty.
+func (c *Cache) Import() error {
+	file, err := os.Open(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if c.compress {
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var entries map[string]Entry
+	if err := gob.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = entries
+
+	return nil
+}
+
+// Export persists the cache's current entries to its backing file.
+func (c *Cache) Export() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var zw *zstd.Encoder
+	if c.compress {
+		var err error
+		zw, err = zstd.NewWriter(&buf)
+		if err != nil {
+			return err
+		}
+		w = zw
+	}
+	if err := gob.NewEncoder(w).Encode(c.entries); err != nil {
+		if zw != nil {
+			zw.Close()
+		}
+		return err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(c.path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheExportImportRoundTrip(t *testing.T) {
+	for _, compress := range []bool{true, false} {
+		path := filepath.Join(t.TempDir(), "cache.gob")
+
+		c := New(path, time.Hour, compress)
+		c.Set("https://example.com/favicon.ico", Entry{
+			Body:   []byte("icon-bytes"),
+			ETag:   `"abc123"`,
+			Murmur: 42,
+			MD5:    "deadbeef",
+		})
+
+		if err := c.Export(); err != nil {
+			t.Fatalf("Export (compress=%v) returned error: %v", compress, err)
+		}
+
+		loaded := New(path, time.Hour, compress)
+		if err := loaded.Import(); err != nil {
+			t.Fatalf("Import (compress=%v) returned error: %v", compress, err)
+		}
+
+		entry, ok := loaded.Get("https://example.com/favicon.ico")
+		if !ok {
+			t.Fatalf("Get (compress=%v): entry not found after round-trip", compress)
+		}
+		if string(entry.Body) != "icon-bytes" || entry.Murmur != 42 || entry.MD5 != "deadbeef" {
+			t.Errorf("Get (compress=%v) = %+v, want matching round-tripped entry", compress, entry)
+		}
+	}
+}
+
+func TestCacheImportMissingFile(t *testing.T) {
+	c := New(filepath.Join(t.TempDir(), "does-not-exist.gob"), time.Hour, true)
+	if err := c.Import(); err != nil {
+		t.Fatalf("Import on missing file returned error: %v", err)
+	}
+	if _, ok := c.Get("anything"); ok {
+		t.Error("Get on empty cache returned a hit")
+	}
+}
+
+func TestCacheGetExpired(t *testing.T) {
+	c := New(filepath.Join(t.TempDir(), "cache.gob"), time.Millisecond, true)
+	c.Set("https://example.com/favicon.ico", Entry{Murmur: 1})
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("https://example.com/favicon.ico"); ok {
+		t.Error("Get returned a hit for an expired entry")
+	}
+}
@@ -0,0 +1,39 @@
+// Package cdn detects whether a host sits behind a CDN, WAF, or cloud
+// provider (Cloudflare, Akamai, Fastly, etc.), mirroring the three-value
+// {matched, provider, type} shape httpx exposes via its CdnCheck.
+package cdn
+
+import "github.com/projectdiscovery/cdncheck"
+
+// Result is the outcome of checking a single host.
+type Result struct {
+	Matched  bool   `json:"matched"`
+	Provider string `json:"provider,omitempty"`
+	Type     string `json:"type,omitempty"` // cdn, waf, or cloud
+}
+
+// Checker resolves hosts to IPs and checks them against the cdncheck
+// provider ranges.
+type Checker struct {
+	client *cdncheck.Client
+}
+
+// New returns a Checker backed by cdncheck's default resolvers.
+func New() (*Checker, error) {
+	client, err := cdncheck.NewWithOpts(3, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Checker{client: client}, nil
+}
+
+// Check resolves host (a bare hostname, no scheme/port) and reports whether
+// it belongs to a known CDN, WAF, or cloud provider.
+func (c *Checker) Check(host string) (Result, error) {
+	matched, provider, itemType, err := c.client.CheckDomainWithFallback(host)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Matched: matched, Provider: provider, Type: itemType}, nil
+}
@@ -0,0 +1,436 @@
+// Package favicon implements the core favicon discovery, fetch, and hashing
+// pipeline shared by the favinfo CLI, its HTTP daemon mode, and its offline
+// fingerprint subcommands.
+package favicon
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/rix4uni/favinfo/cache"
+	"github.com/rix4uni/favinfo/cdn"
+	"github.com/twmb/murmur3"
+)
+
+// Icon represents a single discovered icon candidate.
+type Icon struct {
+	URL    string `json:"url"`
+	Source string `json:"source"` // html-link, manifest, meta, or probed
+	Size   string `json:"size,omitempty"`
+}
+
+// manifestIcon mirrors an entry in a Web App Manifest's "icons" array.
+type manifestIcon struct {
+	Src     string `json:"src"`
+	Sizes   string `json:"sizes"`
+	Type    string `json:"type"`
+	Purpose string `json:"purpose"`
+}
+
+// webAppManifest mirrors the subset of the Web App Manifest spec we care about.
+type webAppManifest struct {
+	Icons []manifestIcon `json:"icons"`
+}
+
+// DefaultProbePaths are the common favicon locations checked when nothing is
+// discoverable from the page markup or its manifest, and GetIcons is called
+// with a nil/empty probePaths.
+var DefaultProbePaths = []string{
+	"/favicon.ico",
+	"/favicon.png",
+	"/favicon.svg",
+	"/apple-touch-icon.png",
+	"/apple-touch-icon-precomposed.png",
+}
+
+// Result represents the result structure for JSON output.
+type Result struct {
+	InputURL   string     `json:"input_url"`
+	FaviconURL string     `json:"favicon_url"`
+	DataURI    bool       `json:"data_uri,omitempty"`
+	MurmurHash int32      `json:"murmur_hash"`
+	MD5Hash    string     `json:"md5_hash"`
+	SHA256Hash string     `json:"sha256_hash"`
+	Technology string     `json:"technology"`
+	CDN        cdn.Result `json:"cdn,omitzero"`
+}
+
+// SearchEngineQueries represents search engine query formats.
+type SearchEngineQueries struct {
+	Shodan  string `json:"shodan"`
+	Fofa    string `json:"fofa"`
+	Censys  string `json:"censys"`
+	ZoomEye string `json:"zoomeye"`
+	Quake   string `json:"quake"`
+}
+
+// ExtendedResult includes search engine queries alongside the base result.
+type ExtendedResult struct {
+	Result
+	SearchQueries SearchEngineQueries `json:"search_queries"`
+}
+
+// resolveAgainst turns href into an absolute URL relative to base, cleaning
+// up the trailing .png/.ico extension the same way the rest of the pipeline
+// expects (query parameters stripped). data: URIs are passed through
+// untouched, since they carry no path/query to clean up and truncating them
+// would corrupt the base64 payload.
+func resolveAgainst(base *url.URL, href string) string {
+	if strings.HasPrefix(href, "data:") {
+		return href
+	}
+
+	var absoluteURL string
+	if strings.HasPrefix(href, "http") {
+		absoluteURL = href
+	} else {
+		absoluteURL = base.ResolveReference(&url.URL{Path: href}).String()
+	}
+
+	if strings.Contains(absoluteURL, ".png") {
+		absoluteURL = strings.Split(absoluteURL, ".png")[0] + ".png"
+	} else if strings.Contains(absoluteURL, ".ico") {
+		absoluteURL = strings.Split(absoluteURL, ".ico")[0] + ".ico"
+	}
+
+	return absoluteURL
+}
+
+// fetchManifestIcons downloads the Web App Manifest at manifestURL and
+// returns its declared icons resolved against the manifest's own URL.
+func fetchManifestIcons(manifestURL string, client *http.Client) ([]Icon, error) {
+	resp, err := client.Get(manifestURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	manifestBase, err := url.Parse(manifestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest webAppManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, err
+	}
+
+	var icons []Icon
+	for _, entry := range manifest.Icons {
+		if entry.Src == "" {
+			continue
+		}
+		icons = append(icons, Icon{
+			URL:    resolveAgainst(manifestBase, entry.Src),
+			Source: "manifest",
+			Size:   entry.Sizes,
+		})
+	}
+
+	return icons, nil
+}
+
+// GetIcons extracts all favicon candidates from the given URL: the usual
+// <link rel="icon"> family, Apple/Microsoft touch-icon variants, the Web App
+// Manifest's icons[] array, and (if nothing else turns up) a probe of
+// probePaths. A nil/empty probePaths falls back to DefaultProbePaths.
+func GetIcons(baseURL string, client *http.Client, source bool, probePaths []string) ([]Icon, error) {
+	if len(probePaths) == 0 {
+		probePaths = DefaultProbePaths
+	}
+
+	// Send GET request to the base URL with the custom client (which includes timeout)
+	resp, err := client.Get(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Parse the HTML
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the base URL to handle relative paths
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Slice to hold favicon candidates
+	var icons []Icon
+
+	addIcon := func(absoluteURL, src, size string) {
+		icons = append(icons, Icon{URL: absoluteURL, Source: src, Size: size})
+
+		// If source flag is set, print the discovery origin
+		if source {
+			fmt.Printf("[%s]: %s\n", src, absoluteURL)
+		}
+	}
+
+	// Find all <link rel="icon">, "shortcut icon", "apple-touch-icon",
+	// "apple-touch-icon-precomposed", and "mask-icon" elements
+	doc.Find("link[rel='icon'], link[rel=\"icon\"], " +
+		"link[rel='shortcut icon'], link[rel=\"shortcut icon\"], " +
+		"link[rel='apple-touch-icon'], link[rel=\"apple-touch-icon\"], " +
+		"link[rel='apple-touch-icon-precomposed'], link[rel=\"apple-touch-icon-precomposed\"], " +
+		"link[rel='mask-icon'], link[rel=\"mask-icon\"]").Each(func(i int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		if !exists {
+			return
+		}
+		sizes, _ := s.Attr("sizes")
+		addIcon(resolveAgainst(base, href), "html-link", sizes)
+	})
+
+	// Find Microsoft tile meta tags (msapplication-TileImage, msapplication-square*)
+	doc.Find("meta[name]").Each(func(i int, s *goquery.Selection) {
+		name, _ := s.Attr("name")
+		if name != "msapplication-TileImage" && !strings.HasPrefix(name, "msapplication-square") {
+			return
+		}
+		content, exists := s.Attr("content")
+		if !exists || content == "" {
+			return
+		}
+		addIcon(resolveAgainst(base, content), "meta", "")
+	})
+
+	// Follow <link rel="manifest"> and pull in its icons[] array
+	if manifestHref, exists := doc.Find("link[rel='manifest'], link[rel=\"manifest\"]").First().Attr("href"); exists {
+		manifestURL := base.ResolveReference(&url.URL{Path: manifestHref}).String()
+		if strings.HasPrefix(manifestHref, "http") {
+			manifestURL = manifestHref
+		}
+
+		manifestIcons, err := fetchManifestIcons(manifestURL, client)
+		if err == nil {
+			for _, icon := range manifestIcons {
+				addIcon(icon.URL, icon.Source, icon.Size)
+			}
+		}
+	}
+
+	// If nothing was found, probe a set of common favicon paths. A 200
+	// status alone isn't enough to trust a hit: SPA-style catch-all routes
+	// (history-mode client-side routing behind nginx try_files, Next.js, or
+	// Netlify-style rewrites) happily return 200 with the HTML shell for any
+	// path, so require an image Content-Type too. Stop at the first real
+	// hit instead of reporting every probed path as its own icon.
+	if len(icons) == 0 {
+		for _, p := range probePaths {
+			probeURL := base.ResolveReference(&url.URL{Path: p}).String()
+
+			resp, err := client.Get(probeURL)
+			if err != nil {
+				continue
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode == 200 && strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+				addIcon(probeURL, "probed", "")
+				break
+			}
+		}
+	}
+
+	return icons, nil
+}
+
+// MurmurHash processes the favicon data and calculates the Murmur3 hash.
+func MurmurHash(faviconBytes []byte) int32 {
+	// Base64 encode the favicon content
+	base64Content := base64.StdEncoding.EncodeToString(faviconBytes)
+
+	// Split the base64 string into chunks as done in the original code
+	chunkSize := 76
+	var chunks []string
+	for i := 0; i*chunkSize+chunkSize < len(base64Content); i++ {
+		chunks = append(chunks, base64Content[i*chunkSize:i*chunkSize+chunkSize])
+	}
+
+	// Add the last chunk
+	lastChunk := base64Content[len(chunks)*chunkSize:]
+	chunks = append(chunks, lastChunk)
+
+	// Combine all chunks into a single string
+	finalString := ""
+	for _, chunk := range chunks {
+		finalString = finalString + chunk + "\n"
+	}
+
+	// Calculate the Murmur3 hash of the final string
+	return int32(murmur3.StringSum32(finalString))
+}
+
+// MD5Hash calculates the MD5 hash of the favicon data.
+func MD5Hash(faviconBytes []byte) string {
+	hash := md5.New()
+	hash.Write(faviconBytes)
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// SHA256Hash calculates the SHA256 hash of the favicon data.
+func SHA256Hash(faviconBytes []byte) string {
+	hash := sha256.New()
+	hash.Write(faviconBytes)
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// EnsureProtocol prefixes input with https:// (falling back to http://) if
+// it doesn't already carry a scheme.
+func EnsureProtocol(input string, client *http.Client) string {
+	if !strings.HasPrefix(input, "http://") && !strings.HasPrefix(input, "https://") {
+		// Try HTTPS first
+		testURL := "https://" + input
+		resp, err := client.Head(testURL) // Use HEAD to check availability quickly
+		if err == nil && resp.StatusCode == http.StatusOK {
+			return testURL
+		}
+		// Fallback to HTTP
+		return "http://" + input
+	}
+	return input
+}
+
+// GenerateSearchQueries generates search engine queries for the given hashes.
+func GenerateSearchQueries(murmurHash int32, md5Hash string) SearchEngineQueries {
+	return SearchEngineQueries{
+		Shodan:  fmt.Sprintf("http.favicon.hash:%d", murmurHash),
+		Fofa:    fmt.Sprintf("icon_hash=\"%d\"", murmurHash),
+		Censys:  fmt.Sprintf("services.http.response.favicons.md5_hash=\"%s\"", md5Hash),
+		ZoomEye: fmt.Sprintf("iconhash:%d", murmurHash),
+		Quake:   fmt.Sprintf("favicon.hash:%d", murmurHash),
+	}
+}
+
+// Fetch fetches the favicon from the given URL, decoding it directly when it
+// is an inlined "data:" URI instead of issuing an HTTP request.
+func Fetch(iconURL string, client *http.Client) ([]byte, error) {
+	if strings.HasPrefix(iconURL, "data:") {
+		_, data, err := DecodeDataURI(iconURL)
+		return data, err
+	}
+
+	response, err := client.Get(iconURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return ioutil.ReadAll(response.Body)
+}
+
+// DecodeDataURI parses a "data:<mediatype>;base64,<payload>" URI and returns
+// its declared media type alongside the decoded payload bytes.
+func DecodeDataURI(uri string) (mediaType string, data []byte, err error) {
+	rest := strings.TrimPrefix(uri, "data:")
+
+	commaIdx := strings.Index(rest, ",")
+	if commaIdx == -1 {
+		return "", nil, fmt.Errorf("invalid data URI: missing comma separator")
+	}
+
+	meta, payload := rest[:commaIdx], rest[commaIdx+1:]
+
+	isBase64 := strings.HasSuffix(meta, ";base64")
+	mediaType = strings.TrimSuffix(meta, ";base64")
+	if mediaType == "" {
+		mediaType = "text/plain;charset=US-ASCII"
+	}
+
+	if !isBase64 {
+		decoded, err := url.QueryUnescape(payload)
+		if err != nil {
+			return "", nil, fmt.Errorf("decoding data URI payload: %w", err)
+		}
+		return mediaType, []byte(decoded), nil
+	}
+
+	data, err = base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("decoding base64 data URI: %w", err)
+	}
+
+	return mediaType, data, nil
+}
+
+// TruncateDataURI shortens a data: URI for display purposes, keeping enough
+// of the payload to identify it without dumping the full base64 blob.
+func TruncateDataURI(uri string) string {
+	const maxLen = 64
+	if len(uri) <= maxLen {
+		return uri
+	}
+	return uri[:maxLen] + "..."
+}
+
+// FetchAndHash fetches iconURL and returns its body alongside its murmur/MD5/
+// SHA256 hashes. When faviconCache is non-nil and the URL was seen before, it
+// revalidates with If-None-Match/If-Modified-Since and, on a 304, returns the
+// cached body/hashes without re-fetching or recomputing anything.
+func FetchAndHash(iconURL string, client *http.Client, faviconCache *cache.Cache) (body []byte, murmurHash int32, md5Hash, sha256Hash string, err error) {
+	if faviconCache == nil || strings.HasPrefix(iconURL, "data:") {
+		body, err = Fetch(iconURL, client)
+		if err != nil {
+			return nil, 0, "", "", err
+		}
+		return body, MurmurHash(body), MD5Hash(body), SHA256Hash(body), nil
+	}
+
+	req, err := http.NewRequest("GET", iconURL, nil)
+	if err != nil {
+		return nil, 0, "", "", err
+	}
+
+	cached, hit := faviconCache.Get(iconURL)
+	if hit {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, "", "", err
+	}
+	defer resp.Body.Close()
+
+	if hit && resp.StatusCode == http.StatusNotModified {
+		return cached.Body, cached.Murmur, cached.MD5, cached.SHA256, nil
+	}
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, "", "", err
+	}
+
+	murmurHash = MurmurHash(body)
+	md5Hash = MD5Hash(body)
+	sha256Hash = SHA256Hash(body)
+
+	faviconCache.Set(iconURL, cache.Entry{
+		Body:         body,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+		Murmur:       murmurHash,
+		MD5:          md5Hash,
+		SHA256:       sha256Hash,
+	})
+
+	return body, murmurHash, md5Hash, sha256Hash, nil
+}
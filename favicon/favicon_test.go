@@ -0,0 +1,49 @@
+package favicon
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeDataURIBase64(t *testing.T) {
+	// "hi" base64-encoded, as found in inlined favicons.
+	mediaType, data, err := DecodeDataURI("data:image/png;base64,aGk=")
+	if err != nil {
+		t.Fatalf("DecodeDataURI returned error: %v", err)
+	}
+	if mediaType != "image/png" {
+		t.Errorf("mediaType = %q, want %q", mediaType, "image/png")
+	}
+	if !bytes.Equal(data, []byte("hi")) {
+		t.Errorf("data = %q, want %q", data, "hi")
+	}
+}
+
+func TestDecodeDataURIPercentEncoded(t *testing.T) {
+	mediaType, data, err := DecodeDataURI("data:text/plain,hello%20world")
+	if err != nil {
+		t.Fatalf("DecodeDataURI returned error: %v", err)
+	}
+	if mediaType != "text/plain" {
+		t.Errorf("mediaType = %q, want %q", mediaType, "text/plain")
+	}
+	if string(data) != "hello world" {
+		t.Errorf("data = %q, want %q", data, "hello world")
+	}
+}
+
+func TestDecodeDataURIDefaultMediaType(t *testing.T) {
+	mediaType, _, err := DecodeDataURI("data:,plain")
+	if err != nil {
+		t.Fatalf("DecodeDataURI returned error: %v", err)
+	}
+	if mediaType != "text/plain;charset=US-ASCII" {
+		t.Errorf("mediaType = %q, want default", mediaType)
+	}
+}
+
+func TestDecodeDataURIMissingComma(t *testing.T) {
+	if _, _, err := DecodeDataURI("data:image/png;base64"); err == nil {
+		t.Fatal("expected error for data URI missing comma separator")
+	}
+}
@@ -0,0 +1,103 @@
+package favicon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetIconsHTMLLink(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="icon" href="/assets/icon.png"></head></html>`))
+	}))
+	defer srv.Close()
+
+	icons, err := GetIcons(srv.URL, srv.Client(), false, nil)
+	if err != nil {
+		t.Fatalf("GetIcons returned error: %v", err)
+	}
+	if len(icons) != 1 || icons[0].Source != "html-link" || icons[0].URL != srv.URL+"/assets/icon.png" {
+		t.Errorf("icons = %+v, want a single html-link icon at /assets/icon.png", icons)
+	}
+}
+
+func TestGetIconsMetaTile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><meta name="msapplication-TileImage" content="/tile.png"></head></html>`))
+	}))
+	defer srv.Close()
+
+	icons, err := GetIcons(srv.URL, srv.Client(), false, nil)
+	if err != nil {
+		t.Fatalf("GetIcons returned error: %v", err)
+	}
+	if len(icons) != 1 || icons[0].Source != "meta" {
+		t.Errorf("icons = %+v, want a single meta icon", icons)
+	}
+}
+
+func TestGetIconsManifest(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="manifest" href="/manifest.json"></head></html>`))
+	})
+	mux.HandleFunc("/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/manifest+json")
+		w.Write([]byte(`{"icons":[{"src":"/icons/192.png","sizes":"192x192"}]}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	icons, err := GetIcons(srv.URL, srv.Client(), false, nil)
+	if err != nil {
+		t.Fatalf("GetIcons returned error: %v", err)
+	}
+	if len(icons) != 1 || icons[0].Source != "manifest" || icons[0].URL != srv.URL+"/icons/192.png" {
+		t.Errorf("icons = %+v, want a single manifest icon at /icons/192.png", icons)
+	}
+}
+
+func TestGetIconsProbeRequiresImageContentType(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			w.Header().Set("Content-Type", "image/x-icon")
+			w.Write([]byte("icon-bytes"))
+			return
+		}
+		// Every other path, including every other default probe path, is a
+		// 200 HTML shell: the SPA catch-all / history-mode routing case.
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>spa shell</html>"))
+	}))
+	defer srv.Close()
+
+	icons, err := GetIcons(srv.URL, srv.Client(), false, nil)
+	if err != nil {
+		t.Fatalf("GetIcons returned error: %v", err)
+	}
+	if len(icons) != 1 {
+		t.Fatalf("icons = %+v, want exactly one probed icon", icons)
+	}
+	if icons[0].Source != "probed" || icons[0].URL != srv.URL+"/favicon.ico" {
+		t.Errorf("icons[0] = %+v, want the image/x-icon hit at /favicon.ico", icons[0])
+	}
+}
+
+func TestGetIconsProbeAllCatchAllHTML(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>spa shell</html>"))
+	}))
+	defer srv.Close()
+
+	icons, err := GetIcons(srv.URL, srv.Client(), false, nil)
+	if err != nil {
+		t.Fatalf("GetIcons returned error: %v", err)
+	}
+	if len(icons) != 0 {
+		t.Errorf("icons = %+v, want none: every probe hit the HTML catch-all", icons)
+	}
+}
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"crypto/md5"
-	"crypto/sha256"
+	"bufio"
 	"crypto/tls"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -12,137 +10,53 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/rix4uni/favinfo/banner"
+	"github.com/rix4uni/favinfo/cache"
+	"github.com/rix4uni/favinfo/cdn"
+	"github.com/rix4uni/favinfo/favicon"
+	"github.com/rix4uni/favinfo/fingerprint"
+	"github.com/rix4uni/favinfo/server"
 	"github.com/spf13/pflag"
-	"github.com/twmb/murmur3"
 )
 
-// FaviconResult represents the result structure for JSON output
-type FaviconResult struct {
-	InputURL   string `json:"input_url"`
-	FaviconURL string `json:"favicon_url"`
-	MurmurHash int32  `json:"murmur_hash"`
-	MD5Hash    string `json:"md5_hash"`
-	SHA256Hash string `json:"sha256_hash"`
-	Technology string `json:"technology"`
-}
-
-// SearchEngineQueries represents search engine query formats
-type SearchEngineQueries struct {
-	Shodan  string `json:"shodan"`
-	Fofa    string `json:"fofa"`
-	Censys  string `json:"censys"`
-	ZoomEye string `json:"zoomeye"`
-	Quake   string `json:"quake"`
-}
-
-// ExtendedFaviconResult includes search engine queries
-type ExtendedFaviconResult struct {
-	FaviconResult
-	SearchQueries SearchEngineQueries `json:"search_queries"`
-}
+// fingerprintSourceURL is where fingerprint.json is downloaded from when no
+// local copy can be found.
+const fingerprintSourceURL = "https://raw.githubusercontent.com/rix4uni/favinfo/refs/heads/main/fingerprint.json"
 
-// getFaviconUrls extracts all favicons from the given URL.
-func getFaviconUrls(baseURL string, client *http.Client, source bool) ([]string, error) {
-	// Send GET request to the base URL with the custom client (which includes timeout)
-	resp, err := client.Get(baseURL)
-	if err != nil {
-		return nil, err
+// resolveFingerprintPath determines which fingerprint.json to use: the
+// explicit customPath if set, otherwise $HOME/.config/favinfo/fingerprint.json
+// or ./fingerprint.json, downloading a fresh copy if none of those exist.
+func resolveFingerprintPath(customPath string, verbose bool) (string, error) {
+	if customPath != "" {
+		return customPath, nil
 	}
-	defer resp.Body.Close()
 
-	// Parse the HTML
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("getting home directory: %w", err)
 	}
 
-	// Parse the base URL to handle relative paths
-	base, err := url.Parse(baseURL)
-	if err != nil {
-		return nil, err
+	configPath := filepath.Join(homeDir, ".config", "favinfo", "fingerprint.json")
+	if _, err := os.Stat(configPath); err == nil {
+		return configPath, nil
 	}
-
-	// Slice to hold favicon URLs
-	var favicons []string
-
-	// Find all <link rel="icon"> and <link rel="shortcut icon"> elements
-	doc.Find("link[rel='icon'], link[rel=\"icon\"], link[rel='shortcut icon'], link[rel=\"shortcut icon\"]").Each(func(i int, s *goquery.Selection) {
-		// Get the href attribute (favicon path)
-		href, exists := s.Attr("href")
-		if exists {
-			// If the href is an absolute URL (starts with http), use it directly
-			var absoluteURL string
-			if strings.HasPrefix(href, "http") {
-				absoluteURL = href
-			} else {
-				// If it's a relative URL, resolve it using the base URL
-				absoluteURL = base.ResolveReference(&url.URL{Path: href}).String()
-			}
-
-			// Remove everything after .png or .ico (strip query parameters)
-			if strings.Contains(absoluteURL, ".png") {
-				absoluteURL = strings.Split(absoluteURL, ".png")[0] + ".png"
-			} else if strings.Contains(absoluteURL, ".ico") {
-				absoluteURL = strings.Split(absoluteURL, ".ico")[0] + ".ico"
-			}
-
-			// Append the cleaned URL
-			favicons = append(favicons, absoluteURL)
-
-			// If source flag is set, print the [Scraped] message
-			if source {
-				fmt.Printf("[Scraped]: %s\n", absoluteURL)
-			}
-		}
-	})
-
-	// If no favicons were found, try the /favicon.ico path
-	if len(favicons) == 0 {
-		// Try adding "/favicon.ico" to the base domain
-		faviconURL := base.ResolveReference(&url.URL{Path: "/favicon.ico"}).String()
-
-		// Check if this URL returns a 200 status code
-		resp, err := client.Get(faviconURL)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		// If status code is 200, return the favicon URL
-		if resp.StatusCode == 200 {
-			favicons = append(favicons, faviconURL)
-
-			// If source flag is set, print the [Added] message
-			if source {
-				fmt.Printf("[Added]: %s\n", faviconURL)
-			}
-		}
+	if _, err := os.Stat("fingerprint.json"); err == nil {
+		return "fingerprint.json", nil
 	}
 
-	return favicons, nil
-}
-
-// loadFingerprintMap loads the fingerprint mapping from the fingerprint.json file.
-func loadFingerprintMap(fileName string) (map[string]string, error) {
-	file, err := os.Open(fileName)
-	if err != nil {
-		return nil, err
+	if verbose {
+		fmt.Println("fingerprint.json not found. Downloading from GitHub...")
 	}
-	defer file.Close()
-
-	var fingerprintMap map[string]string
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&fingerprintMap)
-	if err != nil {
-		return nil, err
+	if err := downloadFingerprintFile(fingerprintSourceURL, configPath); err != nil {
+		return "", fmt.Errorf("downloading fingerprint.json: %w", err)
 	}
 
-	return fingerprintMap, nil
+	return configPath, nil
 }
 
 // downloadFingerprintFile downloads the fingerprint.json file from GitHub and saves it to the specified path.
@@ -178,109 +92,247 @@ func downloadFingerprintFile(url string, filePath string) error {
 	return nil
 }
 
-// calculateMurmurHash processes the favicon data and calculates the Murmur3 hash.
-func calculateMurmurHash(faviconBytes []byte) int32 {
-	// Base64 encode the favicon content
-	base64Content := base64.StdEncoding.EncodeToString(faviconBytes)
+// formatJSONOutput renders a single result in JSON format
+func formatJSONOutput(inputURL string, faviconURL string, murmurHash int32, md5Hash, sha256Hash, tech string, cdnResult cdn.Result) string {
+	isDataURI := strings.HasPrefix(faviconURL, "data:")
+	displayURL := faviconURL
+	if isDataURI {
+		displayURL = favicon.TruncateDataURI(faviconURL)
+	}
 
-	// Split the base64 string into chunks as done in the original code
-	chunkSize := 76
-	var chunks []string
-	for i := 0; i*chunkSize+chunkSize < len(base64Content); i++ {
-		chunks = append(chunks, base64Content[i*chunkSize:i*chunkSize+chunkSize])
+	result := favicon.ExtendedResult{
+		Result: favicon.Result{
+			InputURL:   inputURL,
+			FaviconURL: displayURL,
+			DataURI:    isDataURI,
+			MurmurHash: murmurHash,
+			MD5Hash:    md5Hash,
+			SHA256Hash: sha256Hash,
+			Technology: tech,
+			CDN:        cdnResult,
+		},
+		SearchQueries: favicon.GenerateSearchQueries(murmurHash, md5Hash),
+	}
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("Error marshaling JSON: %v\n", err)
 	}
+	return string(jsonData) + "\n"
+}
 
-	// Add the last chunk
-	lastChunk := base64Content[len(chunks)*chunkSize:]
-	chunks = append(chunks, lastChunk)
+// formatSimplifiedOutput renders a single result in simplified format: URL [hash1, hash2]
+func formatSimplifiedOutput(inputURL string, murmurHashes []int32) string {
+	if len(murmurHashes) == 0 {
+		return fmt.Sprintf("%s []\n", inputURL)
+	}
 
-	// Combine all chunks into a single string
-	finalString := ""
-	for _, chunk := range chunks {
-		finalString = finalString + chunk + "\n"
+	// Convert int32 hashes to strings
+	hashStrings := make([]string, len(murmurHashes))
+	for i, hash := range murmurHashes {
+		hashStrings[i] = fmt.Sprintf("%d", hash)
 	}
 
-	// Calculate the Murmur3 hash of the final string
-	return int32(murmur3.StringSum32(finalString))
+	return fmt.Sprintf("%s [%s]\n", inputURL, strings.Join(hashStrings, ", "))
 }
 
-// calculateMD5 calculates the MD5 hash of the favicon data.
-func calculateMD5(faviconBytes []byte) string {
-	hash := md5.New()
-	hash.Write(faviconBytes)
-	return fmt.Sprintf("%x", hash.Sum(nil))
+// cdnLookup is satisfied by *cdn.Checker; processURL depends on this
+// instead of the concrete type so its --exclude-cdn/--cdn-only gating can
+// be exercised in tests without live DNS resolution.
+type cdnLookup interface {
+	Check(host string) (cdn.Result, error)
 }
 
-// calculateSHA256 calculates the SHA256 hash of the favicon data.
-func calculateSHA256(faviconBytes []byte) string {
-	hash := sha256.New()
-	hash.Write(faviconBytes)
-	return fmt.Sprintf("%x", hash.Sum(nil))
-}
+// processURL runs the full discovery/fetch/hash pipeline for a single input
+// and renders its output, so it can be farmed out to a worker pool.
+func processURL(input string, client *http.Client, userAgent string, fingerprintDB *fingerprint.DB, jsonOutput, source bool, faviconCache *cache.Cache, cdnChecker cdnLookup, excludeCDN, cdnOnly bool, probePaths []string) string {
+	var out strings.Builder
 
-func ensureProtocol(input string, client *http.Client) string {
-	if !strings.HasPrefix(input, "http://") && !strings.HasPrefix(input, "https://") {
-		// Try HTTPS first
-		testURL := "https://" + input
-		resp, err := client.Head(testURL) // Use HEAD to check availability quickly
-		if err == nil && resp.StatusCode == http.StatusOK {
-			return testURL
+	// Set User-Agent header
+	req, err := http.NewRequest("GET", input, nil)
+	if err != nil {
+		out.WriteString(fmt.Sprintf("Error creating request: %v\n", err))
+		return out.String()
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	processedInput := favicon.EnsureProtocol(input, client)
+
+	// Resolve the target host and check it against known CDN/WAF/cloud
+	// provider ranges. A failed check (e.g. DNS resolution error) is treated
+	// the same as "not behind a CDN" rather than aborting the scan.
+	var cdnResult cdn.Result
+	if cdnChecker != nil {
+		if host := hostOf(processedInput); host != "" {
+			if res, err := cdnChecker.Check(host); err == nil {
+				cdnResult = res
+			}
 		}
-		// Fallback to HTTP
-		return "http://" + input
 	}
-	return input
-}
 
-// generateSearchQueries generates search engine queries for the given hashes
-func generateSearchQueries(murmurHash int32, md5Hash string) SearchEngineQueries {
-	return SearchEngineQueries{
-		Shodan:  fmt.Sprintf("http.favicon.hash:%d", murmurHash),
-		Fofa:    fmt.Sprintf("icon_hash=\"%d\"", murmurHash),
-		Censys:  fmt.Sprintf("services.http.response.favicons.md5_hash=\"%s\"", md5Hash),
-		ZoomEye: fmt.Sprintf("iconhash:%d", murmurHash),
-		Quake:   fmt.Sprintf("favicon.hash:%d", murmurHash),
+	// The favicon returned by a CDN/WAF is often just its default image and
+	// pollutes hash results, so skip discovery/fetching entirely when the
+	// target's CDN status doesn't match what the caller asked for.
+	if (excludeCDN && cdnResult.Matched) || (cdnOnly && !cdnResult.Matched) {
+		if jsonOutput {
+			out.WriteString(formatJSONOutput(processedInput, "", 0, "", "", "", cdnResult))
+		} else {
+			out.WriteString(formatSimplifiedOutput(processedInput, nil))
+		}
+		return out.String()
 	}
-}
 
-// printJSONOutput prints the results in JSON format
-func printJSONOutput(inputURL string, faviconURL string, murmurHash int32, md5Hash, sha256Hash, tech string) {
-	result := ExtendedFaviconResult{
-		FaviconResult: FaviconResult{
-			InputURL:   inputURL,
-			FaviconURL: faviconURL,
-			MurmurHash: murmurHash,
-			MD5Hash:    md5Hash,
-			SHA256Hash: sha256Hash,
-			Technology: tech,
-		},
-		SearchQueries: generateSearchQueries(murmurHash, md5Hash),
+	// Fetch the favicons
+	favicons, err := favicon.GetIcons(processedInput, client, source, probePaths)
+	if err != nil {
+		out.WriteString(fmt.Sprintf("Error fetching favicons: %v\n", err))
+		return out.String()
 	}
-	jsonData, err := json.MarshalIndent(result, "", "  ")
+
+	// Output based on format
+	if jsonOutput {
+		// JSON output: process each favicon candidate separately
+		for _, icon := range favicons {
+			// Fetch the favicon content (cache-aware, computes/reuses hashes)
+			_, murmurHash, md5Hash, sha256Hash, err := favicon.FetchAndHash(icon.URL, client, faviconCache)
+			if err != nil {
+				out.WriteString(fmt.Sprintf("Error fetching favicon content: %v\n", err))
+				continue
+			}
+
+			// Find the technology based on the Murmur3 hash
+			tech := "unknown"
+			if techs := fingerprintDB.Lookup(murmurHash); len(techs) > 0 {
+				tech = strings.Join(techs, ", ")
+			}
+
+			out.WriteString(formatJSONOutput(processedInput, icon.URL, murmurHash, md5Hash, sha256Hash, tech, cdnResult))
+		}
+	} else {
+		// Simplified output: collect murmur hashes from all favicon candidates
+		var murmurHashes []int32
+		for _, icon := range favicons {
+			// Fetch the favicon content (cache-aware, computes/reuses hashes)
+			_, murmurHash, _, _, err := favicon.FetchAndHash(icon.URL, client, faviconCache)
+			if err != nil {
+				// Skip this favicon if fetch fails, but continue with others
+				continue
+			}
+
+			murmurHashes = append(murmurHashes, murmurHash)
+		}
+
+		// Render simplified output
+		out.WriteString(formatSimplifiedOutput(processedInput, murmurHashes))
+	}
+
+	return out.String()
+}
+
+// hostOf returns the bare hostname (no port) of rawURL, or "" if it can't be parsed.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		fmt.Printf("Error marshaling JSON: %v\n", err)
-		return
+		return ""
 	}
-	fmt.Println(string(jsonData))
+	return u.Hostname()
 }
 
-// printSimplifiedOutput prints the results in simplified format: URL [hash1, hash2]
-func printSimplifiedOutput(inputURL string, murmurHashes []int32) {
-	if len(murmurHashes) == 0 {
-		fmt.Printf("%s []\n", inputURL)
-		return
+// poolJob is a single unit of work handed to the worker pool, tagged with
+// its position in the input stream so order can be restored afterward.
+type poolJob struct {
+	index int
+	url   string
+}
+
+// poolResult is the output of a poolJob, still tagged with its input index.
+type poolResult struct {
+	index  int
+	output string
+}
+
+// runPool fans urls out across concurrency workers calling process for
+// each, rate-limited by limiter when non-nil. Results are emitted as soon
+// as each worker finishes when stream is true, otherwise they are buffered
+// and emitted back in input order. urls may be fed incrementally (e.g. from
+// a stdin scanner) without needing to be collected upfront first.
+func runPool(urls <-chan string, concurrency int, stream bool, limiter <-chan time.Time, process func(url string) string, emit func(output string)) {
+	jobs := make(chan poolJob)
+	results := make(chan poolResult)
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for j := range jobs {
+				if limiter != nil {
+					<-limiter
+				}
+				results <- poolResult{index: j.index, output: process(j.url)}
+			}
+		}()
 	}
 
-	// Convert int32 hashes to strings
-	hashStrings := make([]string, len(murmurHashes))
-	for i, hash := range murmurHashes {
-		hashStrings[i] = fmt.Sprintf("%d", hash)
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	go func() {
+		index := 0
+		for u := range urls {
+			jobs <- poolJob{index: index, url: u}
+			index++
+		}
+		close(jobs)
+	}()
+
+	if stream {
+		// Emit results as soon as each worker finishes, regardless of input order
+		for r := range results {
+			emit(r.output)
+		}
+		return
 	}
 
-	fmt.Printf("%s [%s]\n", inputURL, strings.Join(hashStrings, ", "))
+	// Default: reorder results back into input order before emitting
+	pending := make(map[int]string)
+	next := 0
+	for r := range results {
+		pending[r.index] = r.output
+		for {
+			output, ok := pending[next]
+			if !ok {
+				break
+			}
+			emit(output)
+			delete(pending, next)
+			next++
+		}
+	}
 }
 
 func main() {
+	// Dispatch offline fingerprint-database subcommands and the HTTP daemon
+	// mode before the normal URL-scanning flags are parsed:
+	// favinfo lookup|search|add|serve ...
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lookup":
+			runLookup(os.Args[2:])
+			return
+		case "search":
+			runSearch(os.Args[2:])
+			return
+		case "add":
+			runAdd(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		}
+	}
+
 	// Define the flags using pflag
 	timeout := pflag.Duration("timeout", 30*time.Second, "Set the HTTP request timeout duration")
 	source := pflag.Bool("source", false, "Enable source output for where the url coming from scraped or added /favicon.ico")
@@ -290,6 +342,16 @@ func main() {
 	silent := pflag.Bool("silent", false, "Silent mode.")
 	version := pflag.Bool("version", false, "Print the version of the tool and exit.")
 	verbose := pflag.Bool("verbose", false, "Verbose mode. Show verbose output.")
+	concurrency := pflag.IntP("concurrency", "c", 25, "Number of concurrent workers processing input URLs")
+	rate := pflag.Float64("rate", 0, "Maximum requests per second across all workers (0 = unlimited)")
+	stream := pflag.Bool("stream", false, "Print results as they complete instead of preserving input order")
+	cachePath := pflag.String("cache", "", "Path to the on-disk favicon cache file (default: $HOME/.config/favinfo/cache.gob)")
+	cacheTTL := pflag.Duration("cache-ttl", 24*time.Hour, "How long cached favicon fetches stay valid (0 = never expire)")
+	noCache := pflag.Bool("no-cache", false, "Disable the on-disk favicon cache")
+	cacheCompress := pflag.Bool("cache-compress", true, "Compress the on-disk favicon cache with zstd")
+	excludeCDN := pflag.Bool("exclude-cdn", false, "Skip favicon fetching for targets behind a known CDN/WAF/cloud provider")
+	cdnOnly := pflag.Bool("cdn-only", false, "Only fetch favicons for targets behind a known CDN/WAF/cloud provider")
+	probePaths := pflag.StringSlice("probe-paths", favicon.DefaultProbePaths, "Comma-separated list of paths to probe for a favicon when none is discoverable from the page markup or its manifest")
 
 	// Parse the flags
 	pflag.Parse()
@@ -317,127 +379,338 @@ func main() {
 		DisableKeepAlives: false,
 	}
 
-	// Determine the path to fingerprint.json
-	var fingerprintFilePath string
-	const fingerprintURL = "https://raw.githubusercontent.com/rix4uni/favinfo/refs/heads/main/fingerprint.json"
+	// Determine the path to fingerprint.json and load the fingerprint database
+	fingerprintFilePath, err := resolveFingerprintPath(*fingerprintPath, *verbose)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fingerprintDB, err := fingerprint.Load(fingerprintFilePath)
+	if err != nil {
+		fmt.Printf("Error loading fingerprint.json from %s: %v\n", fingerprintFilePath, err)
+		return
+	}
 
-	if *fingerprintPath != "" {
-		// Use the custom path provided via the flag
-		fingerprintFilePath = *fingerprintPath
-	} else {
-		// Get the user's home directory
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Println("Error getting home directory:", err)
-			return
+	// Set up the on-disk favicon cache, unless disabled
+	var faviconCache *cache.Cache
+	if !*noCache {
+		cacheFilePath := *cachePath
+		if cacheFilePath == "" {
+			if homeDir, err := os.UserHomeDir(); err == nil {
+				cacheFilePath = filepath.Join(homeDir, ".config", "favinfo", "cache.gob")
+			}
 		}
 
-		// Check for fingerprint.json in $HOME/.config/favinfo/
-		configPath := filepath.Join(homeDir, ".config", "favinfo", "fingerprint.json")
-		if _, err := os.Stat(configPath); err == nil {
-			fingerprintFilePath = configPath
-		} else if _, err := os.Stat("fingerprint.json"); err == nil {
-			// Fall back to fingerprint.json in the current directory
-			fingerprintFilePath = "fingerprint.json"
-		} else {
-			// File not found, attempt to download it
-			if *verbose {
-				fmt.Println("fingerprint.json not found. Downloading from GitHub...")
+		if cacheFilePath != "" {
+			if err := os.MkdirAll(filepath.Dir(cacheFilePath), 0755); err != nil {
+				fmt.Printf("Error preparing cache directory: %v\n", err)
+			} else {
+				faviconCache = cache.New(cacheFilePath, *cacheTTL, *cacheCompress)
+				if err := faviconCache.Import(); err != nil && *verbose {
+					fmt.Printf("Error loading favicon cache from %s: %v\n", cacheFilePath, err)
+				}
 			}
-			if err := downloadFingerprintFile(fingerprintURL, configPath); err != nil {
-				fmt.Printf("Error downloading fingerprint.json: %v\n", err)
-				return
+		}
+	}
+
+	// Set up CDN/WAF/cloud detection, enriching every result's "cdn" field.
+	// A failed init (e.g. no DNS egress for the resolver bootstrap) shouldn't
+	// abort the scan; just continue without CDN enrichment.
+	cdnChecker, err := cdn.New()
+	if err != nil {
+		fmt.Printf("Error initializing CDN checker: %v\n", err)
+	}
+	// Box as the cdnLookup interface explicitly: a nil *cdn.Checker boxed
+	// directly into an interface value is non-nil, which would defeat
+	// processURL's "cdnChecker != nil" skip-CDN-detection check.
+	var checker cdnLookup
+	if cdnChecker != nil {
+		checker = cdnChecker
+	}
+
+	// Optional rate limiter shared across all workers (token bucket via ticker)
+	var limiter <-chan time.Time
+	if *rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	// Stream URLs from stdin into the job queue as they arrive
+	urls := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			input := strings.TrimSpace(scanner.Text())
+			if input == "" {
+				continue
 			}
-			fingerprintFilePath = configPath
+			urls <- input
+		}
+		close(urls)
+	}()
+
+	runPool(urls, *concurrency, *stream, limiter, func(url string) string {
+		return processURL(url, client, *userAgent, fingerprintDB, *jsonOutput, *source, faviconCache, checker, *excludeCDN, *cdnOnly, *probePaths)
+	}, func(output string) {
+		fmt.Print(output)
+	})
+
+	if faviconCache != nil {
+		if err := faviconCache.Export(); err != nil {
+			fmt.Printf("Error saving favicon cache: %v\n", err)
 		}
 	}
+}
+
+// runLookup implements "favinfo lookup <hash>": prints the technologies
+// recorded against a murmur hash along with ready-to-use search engine
+// queries, entirely offline.
+func runLookup(args []string) {
+	fs := pflag.NewFlagSet("lookup", pflag.ExitOnError)
+	fingerprintPath := fs.String("fingerprint", "", "Path to the fingerprint.json file (default: $HOME/.config/favinfo/fingerprint.json or ./fingerprint.json)")
+	verbose := fs.Bool("verbose", false, "Verbose mode. Show verbose output.")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: favinfo lookup <hash>")
+		return
+	}
 
-	// Load the fingerprint map
-	fingerprintMap, err := loadFingerprintMap(fingerprintFilePath)
+	hash, err := strconv.ParseInt(fs.Arg(0), 10, 32)
+	if err != nil {
+		fmt.Printf("Invalid hash %q: %v\n", fs.Arg(0), err)
+		return
+	}
+	murmurHash := int32(hash)
+
+	fingerprintFilePath, err := resolveFingerprintPath(*fingerprintPath, *verbose)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	db, err := fingerprint.Load(fingerprintFilePath)
 	if err != nil {
 		fmt.Printf("Error loading fingerprint.json from %s: %v\n", fingerprintFilePath, err)
 		return
 	}
 
-	// Read URL(s) from stdin
-	var input string
-	for {
-		_, err := fmt.Scanln(&input)
-		if err != nil {
-			break
-		}
+	entries := db.EntriesForHash(murmurHash)
+	if len(entries) == 0 {
+		fmt.Printf("No technology found for hash %d\n", murmurHash)
+		return
+	}
 
-		// Set User-Agent header
-		req, err := http.NewRequest("GET", input, nil)
-		if err != nil {
-			fmt.Printf("Error creating request: %v\n", err)
-			continue
+	var md5Hash string
+	for _, e := range entries {
+		fmt.Printf("%d -> %s\n", murmurHash, e.Technology)
+		if md5Hash == "" {
+			md5Hash = e.MD5
 		}
-		req.Header.Set("User-Agent", *userAgent)
-
-		// Fetch the favicons
-		processedInput := ensureProtocol(input, client)
-		favicons, err := getFaviconUrls(processedInput, client, *source)
-		if err != nil {
-			fmt.Printf("Error fetching favicons: %v\n", err)
-			continue
+	}
+
+	queries := favicon.GenerateSearchQueries(murmurHash, md5Hash)
+	jsonData, err := json.MarshalIndent(queries, "", "  ")
+	if err != nil {
+		fmt.Printf("Error marshaling search queries: %v\n", err)
+		return
+	}
+	fmt.Println(string(jsonData))
+}
+
+// runSearch implements "favinfo search <glob>": lists every hash recorded
+// against technology names matching the glob, entirely offline.
+func runSearch(args []string) {
+	fs := pflag.NewFlagSet("search", pflag.ExitOnError)
+	fingerprintPath := fs.String("fingerprint", "", "Path to the fingerprint.json file (default: $HOME/.config/favinfo/fingerprint.json or ./fingerprint.json)")
+	verbose := fs.Bool("verbose", false, "Verbose mode. Show verbose output.")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: favinfo search <tech-glob>")
+		return
+	}
+
+	fingerprintFilePath, err := resolveFingerprintPath(*fingerprintPath, *verbose)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	db, err := fingerprint.Load(fingerprintFilePath)
+	if err != nil {
+		fmt.Printf("Error loading fingerprint.json from %s: %v\n", fingerprintFilePath, err)
+		return
+	}
+
+	matches := db.Search(fs.Arg(0))
+	if len(matches) == 0 {
+		fmt.Printf("No hashes found for technology %q\n", fs.Arg(0))
+		return
+	}
+
+	for _, e := range matches {
+		fmt.Printf("%d\t%s\n", e.Hash, e.Technology)
+	}
+}
+
+// runAdd implements "favinfo add --url <url> --tech <technology>": fetches
+// the target's favicon, hashes it, and appends the result to the user's
+// local fingerprint file.
+func runAdd(args []string) {
+	fs := pflag.NewFlagSet("add", pflag.ExitOnError)
+	targetURL := fs.String("url", "", "URL to fetch the favicon from")
+	tech := fs.String("tech", "", "Technology name to associate with the discovered hash")
+	reference := fs.String("reference", "", "Optional reference URL/note to record for this entry")
+	fingerprintPath := fs.String("fingerprint", "", "Path to the fingerprint.json file (default: $HOME/.config/favinfo/fingerprint.json or ./fingerprint.json)")
+	timeout := fs.Duration("timeout", 30*time.Second, "Set the HTTP request timeout duration")
+	userAgent := fs.StringP("user-agent", "H", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36", "Set the User-Agent header for HTTP requests")
+	probePaths := fs.StringSlice("probe-paths", favicon.DefaultProbePaths, "Comma-separated list of paths to probe for a favicon when none is discoverable from the page markup or its manifest")
+	verbose := fs.Bool("verbose", false, "Verbose mode. Show verbose output.")
+	fs.Parse(args)
+
+	if *targetURL == "" || *tech == "" {
+		fmt.Println("Usage: favinfo add --url <url> --tech <technology>")
+		return
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	client.Transport = &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: false,
+	}
+
+	// Set User-Agent header
+	req, err := http.NewRequest("GET", *targetURL, nil)
+	if err != nil {
+		fmt.Printf("Error creating request: %v\n", err)
+		return
+	}
+	req.Header.Set("User-Agent", *userAgent)
+
+	processedInput := favicon.EnsureProtocol(*targetURL, client)
+	favicons, err := favicon.GetIcons(processedInput, client, false, *probePaths)
+	if err != nil {
+		fmt.Printf("Error fetching favicons: %v\n", err)
+		return
+	}
+	if len(favicons) == 0 {
+		fmt.Printf("No favicon found for %s\n", processedInput)
+		return
+	}
+
+	_, murmurHash, md5Hash, sha256Hash, err := favicon.FetchAndHash(favicons[0].URL, client, nil)
+	if err != nil {
+		fmt.Printf("Error fetching favicon content: %v\n", err)
+		return
+	}
+
+	fingerprintFilePath, err := resolveFingerprintPath(*fingerprintPath, *verbose)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	db, err := fingerprint.Load(fingerprintFilePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Printf("Error loading fingerprint.json from %s: %v\n", fingerprintFilePath, err)
+			return
 		}
+		db = fingerprint.New(fingerprintFilePath)
+	}
 
-		// Output based on format
-		if *jsonOutput {
-			// JSON output: process each favicon separately
-			for _, faviconURL := range favicons {
-				// Fetch the favicon content
-				faviconBytes, err := fetchFavicon(faviconURL, client)
-				if err != nil {
-					fmt.Printf("Error fetching favicon content: %v\n", err)
-					continue
-				}
+	if err := db.Add(murmurHash, md5Hash, sha256Hash, *tech, *reference); err != nil {
+		fmt.Printf("Error saving fingerprint.json to %s: %v\n", fingerprintFilePath, err)
+		return
+	}
 
-				// Calculate the hashes
-				murmurHash := calculateMurmurHash(faviconBytes)
-				md5Hash := calculateMD5(faviconBytes)
-				sha256Hash := calculateSHA256(faviconBytes)
+	fmt.Printf("Added %s (hash=%d, md5=%s) to %s\n", *tech, murmurHash, md5Hash, fingerprintFilePath)
+}
 
-				// Find the technology based on the Murmur3 hash
-				tech := fingerprintMap[fmt.Sprintf("%d", murmurHash)]
-				if tech == "" {
-					tech = "unknown"
-				}
+// runServe implements "favinfo serve --listen :8080": runs the HTTP daemon
+// exposing /hash, /icon, and /fallback.
+func runServe(args []string) {
+	fs := pflag.NewFlagSet("serve", pflag.ExitOnError)
+	listen := fs.String("listen", ":8080", "Address to listen on")
+	timeout := fs.Duration("timeout", 30*time.Second, "Set the HTTP request timeout duration")
+	userAgent := fs.StringP("user-agent", "H", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36", "Set the User-Agent header for HTTP requests")
+	fingerprintPath := fs.String("fingerprint", "", "Path to the fingerprint.json file (default: $HOME/.config/favinfo/fingerprint.json or ./fingerprint.json)")
+	cachePath := fs.String("cache", "", "Path to the on-disk favicon cache file (default: $HOME/.config/favinfo/cache.gob)")
+	cacheTTL := fs.Duration("cache-ttl", 24*time.Hour, "How long cached favicon fetches stay valid (0 = never expire)")
+	noCache := fs.Bool("no-cache", false, "Disable the on-disk favicon cache")
+	cacheCompress := fs.Bool("cache-compress", true, "Compress the on-disk favicon cache with zstd")
+	probePaths := fs.StringSlice("probe-paths", favicon.DefaultProbePaths, "Comma-separated list of paths to probe for a favicon when none is discoverable from the page markup or its manifest")
+	verbose := fs.Bool("verbose", false, "Verbose mode. Show verbose output.")
+	fs.Parse(args)
+
+	fingerprintFilePath, err := resolveFingerprintPath(*fingerprintPath, *verbose)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
-				printJSONOutput(processedInput, faviconURL, murmurHash, md5Hash, sha256Hash, tech)
-			}
-		} else {
-			// Simplified output: collect murmur hashes from all favicons
-			var murmurHashes []int32
-			for _, faviconURL := range favicons {
-				// Fetch the favicon content
-				faviconBytes, err := fetchFavicon(faviconURL, client)
-				if err != nil {
-					// Skip this favicon if fetch fails, but continue with others
-					continue
-				}
+	fingerprintDB, err := fingerprint.Load(fingerprintFilePath)
+	if err != nil {
+		fmt.Printf("Error loading fingerprint.json from %s: %v\n", fingerprintFilePath, err)
+		return
+	}
 
-				// Calculate only the murmur hash
-				murmurHash := calculateMurmurHash(faviconBytes)
-				murmurHashes = append(murmurHashes, murmurHash)
+	var faviconCache *cache.Cache
+	if !*noCache {
+		cacheFilePath := *cachePath
+		if cacheFilePath == "" {
+			if homeDir, err := os.UserHomeDir(); err == nil {
+				cacheFilePath = filepath.Join(homeDir, ".config", "favinfo", "cache.gob")
 			}
+		}
 
-			// Print simplified output
-			printSimplifiedOutput(processedInput, murmurHashes)
+		if cacheFilePath != "" {
+			if err := os.MkdirAll(filepath.Dir(cacheFilePath), 0755); err != nil {
+				fmt.Printf("Error preparing cache directory: %v\n", err)
+			} else {
+				faviconCache = cache.New(cacheFilePath, *cacheTTL, *cacheCompress)
+				if err := faviconCache.Import(); err != nil && *verbose {
+					fmt.Printf("Error loading favicon cache from %s: %v\n", cacheFilePath, err)
+				}
+			}
 		}
 	}
-}
 
-// fetchFavicon fetches the favicon from the given URL.
-func fetchFavicon(url string, client *http.Client) ([]byte, error) {
-	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	client := &http.Client{Timeout: *timeout}
+	client.Transport = &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: false,
+	}
 
-	response, err := client.Get(url)
+	// Set up CDN/WAF/cloud detection, enriching every result's "cdn" field
+	// the same way the CLI's processURL does.
+	cdnChecker, err := cdn.New()
 	if err != nil {
-		return nil, err
+		fmt.Printf("Error initializing CDN checker: %v\n", err)
 	}
-	defer response.Body.Close()
 
-	return ioutil.ReadAll(response.Body)
+	srv := server.New(server.Config{
+		Client:        client,
+		UserAgent:     *userAgent,
+		FingerprintDB: fingerprintDB,
+		Cache:         faviconCache,
+		CDNChecker:    cdnChecker,
+		ProbePaths:    *probePaths,
+	})
+
+	fmt.Printf("favinfo serve: listening on %s\n", *listen)
+	if err := http.ListenAndServe(*listen, srv); err != nil {
+		fmt.Printf("Error running server: %v\n", err)
+	}
+
+	if faviconCache != nil {
+		if err := faviconCache.Export(); err != nil {
+			fmt.Printf("Error saving favicon cache: %v\n", err)
+		}
+	}
 }
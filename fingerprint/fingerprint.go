@@ -0,0 +1,151 @@
+// Package fingerprint provides a bidirectional favicon-hash fingerprint
+// database: murmur hash -> technology name, and technology glob -> hashes.
+package fingerprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Entry is a single fingerprint record.
+type Entry struct {
+	Hash       int32     `json:"hash"`
+	MD5        string    `json:"md5,omitempty"`
+	SHA256     string    `json:"sha256,omitempty"`
+	Technology string    `json:"technology"`
+	Category   string    `json:"category,omitempty"`
+	References []string  `json:"references,omitempty"`
+	AddedAt    time.Time `json:"added_at,omitempty"`
+}
+
+// DB is an in-memory fingerprint database backed by a JSON file on disk.
+type DB struct {
+	mu      sync.RWMutex
+	path    string
+	entries []Entry
+}
+
+// New returns an empty DB backed by path, without reading anything from
+// disk. Useful when curating a brand-new local fingerprint file via Add.
+func New(path string) *DB {
+	return &DB{path: path}
+}
+
+// Load reads path and returns a DB. It accepts the rich []Entry schema, and
+// falls back to the legacy flat map[string]string{"<hash>": "<technology>"}
+// schema for backward compatibility.
+func Load(path string) (*DB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{path: path}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err == nil {
+		db.entries = entries
+		return db, nil
+	}
+
+	var flat map[string]string
+	if err := json.Unmarshal(data, &flat); err != nil {
+		return nil, fmt.Errorf("unrecognized fingerprint.json schema: %w", err)
+	}
+
+	for hashStr, tech := range flat {
+		hash, err := strconv.ParseInt(hashStr, 10, 32)
+		if err != nil {
+			continue
+		}
+		db.entries = append(db.entries, Entry{Hash: int32(hash), Technology: tech})
+	}
+
+	return db, nil
+}
+
+// Lookup returns every technology name recorded against hash.
+func (db *DB) Lookup(hash int32) []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var techs []string
+	for _, e := range db.entries {
+		if e.Hash == hash {
+			techs = append(techs, e.Technology)
+		}
+	}
+
+	return techs
+}
+
+// EntriesForHash returns the full entries (including MD5/SHA256/category/
+// references) recorded against hash, for callers that need more than just
+// the technology name.
+func (db *DB) EntriesForHash(hash int32) []Entry {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var matches []Entry
+	for _, e := range db.entries {
+		if e.Hash == hash {
+			matches = append(matches, e)
+		}
+	}
+
+	return matches
+}
+
+// Search returns every entry whose technology name matches techGlob, using
+// shell-style glob matching (see path.Match).
+func (db *DB) Search(techGlob string) []Entry {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var matches []Entry
+	for _, e := range db.entries {
+		if ok, _ := path.Match(techGlob, e.Technology); ok {
+			matches = append(matches, e)
+		}
+	}
+
+	return matches
+}
+
+// Add appends a new entry for hash/tech, recording md5/sha256 and source as
+// a reference when non-empty, and persists the database to disk.
+func (db *DB) Add(hash int32, md5Hash, sha256Hash, tech, source string) error {
+	db.mu.Lock()
+	entry := Entry{
+		Hash:       hash,
+		MD5:        md5Hash,
+		SHA256:     sha256Hash,
+		Technology: tech,
+		AddedAt:    time.Now(),
+	}
+	if source != "" {
+		entry.References = []string{source}
+	}
+	db.entries = append(db.entries, entry)
+	db.mu.Unlock()
+
+	return db.Save()
+}
+
+// Save writes the database back to its backing file as indented JSON.
+func (db *DB) Save() error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	data, err := json.MarshalIndent(db.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(db.path, data, 0644)
+}
@@ -0,0 +1,77 @@
+package fingerprint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRichSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fingerprint.json")
+	if err := os.WriteFile(path, []byte(`[
+		{"hash": 123, "technology": "nginx", "category": "webserver"},
+		{"hash": 456, "technology": "apache"}
+	]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	techs := db.Lookup(123)
+	if len(techs) != 1 || techs[0] != "nginx" {
+		t.Errorf("Lookup(123) = %v, want [nginx]", techs)
+	}
+}
+
+func TestLoadLegacyFlatSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fingerprint.json")
+	if err := os.WriteFile(path, []byte(`{"123": "nginx", "456": "apache"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	techs := db.Lookup(456)
+	if len(techs) != 1 || techs[0] != "apache" {
+		t.Errorf("Lookup(456) = %v, want [apache]", techs)
+	}
+}
+
+func TestLoadUnrecognizedSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fingerprint.json")
+	if err := os.WriteFile(path, []byte(`"just a string"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected error for unrecognized fingerprint.json schema")
+	}
+}
+
+func TestAddPersistsMD5AndSHA256(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fingerprint.json")
+	db := New(path)
+
+	if err := db.Add(123, "md5hash", "sha256hash", "nginx", "https://example.com"); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	entries := reloaded.EntriesForHash(123)
+	if len(entries) != 1 {
+		t.Fatalf("EntriesForHash(123) = %d entries, want 1", len(entries))
+	}
+	if entries[0].MD5 != "md5hash" || entries[0].SHA256 != "sha256hash" {
+		t.Errorf("entry = %+v, want MD5=md5hash SHA256=sha256hash", entries[0])
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runPoolCollect drains urls through runPool with the given concurrency and
+// stream setting, returning whatever emit received, in emission order.
+func runPoolCollect(urls []string, concurrency int, stream bool, process func(string) string) []string {
+	in := make(chan string)
+	go func() {
+		for _, u := range urls {
+			in <- u
+		}
+		close(in)
+	}()
+
+	var mu sync.Mutex
+	var out []string
+	runPool(in, concurrency, stream, nil, process, func(output string) {
+		mu.Lock()
+		out = append(out, output)
+		mu.Unlock()
+	})
+	return out
+}
+
+func TestRunPoolPreservesOrderByDefault(t *testing.T) {
+	urls := []string{"a", "b", "c", "d", "e"}
+
+	// Make later inputs finish first, so order-preservation can't be an
+	// accident of scheduling.
+	out := runPoolCollect(urls, len(urls), false, func(u string) string {
+		delay := map[string]time.Duration{
+			"a": 20 * time.Millisecond,
+			"b": 15 * time.Millisecond,
+			"c": 10 * time.Millisecond,
+			"d": 5 * time.Millisecond,
+			"e": 0,
+		}[u]
+		time.Sleep(delay)
+		return u
+	})
+
+	if len(out) != len(urls) {
+		t.Fatalf("got %d results, want %d", len(out), len(urls))
+	}
+	for i, u := range urls {
+		if out[i] != u {
+			t.Errorf("out[%d] = %q, want %q (order not preserved): %v", i, out[i], u, out)
+		}
+	}
+}
+
+func TestRunPoolStreamEmitsAsTheyFinish(t *testing.T) {
+	urls := []string{"slow", "fast"}
+
+	out := runPoolCollect(urls, len(urls), true, func(u string) string {
+		if u == "slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return u
+	})
+
+	if len(out) != 2 {
+		t.Fatalf("got %d results, want 2", len(out))
+	}
+	if out[0] != "fast" || out[1] != "slow" {
+		t.Errorf("out = %v, want [fast slow] (fastest finisher emitted first)", out)
+	}
+}
+
+func TestRunPoolProcessesEveryURLRegardlessOfOrder(t *testing.T) {
+	urls := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	out := runPoolCollect(urls, 3, true, func(u string) string { return u })
+
+	sort.Strings(out)
+	sortedURLs := append([]string(nil), urls...)
+	sort.Strings(sortedURLs)
+	if len(out) != len(urls) {
+		t.Fatalf("got %d results, want %d", len(out), len(urls))
+	}
+	for i := range sortedURLs {
+		if out[i] != sortedURLs[i] {
+			t.Errorf("out = %v, want all of %v in some order", out, urls)
+			break
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rix4uni/favinfo/cdn"
+	"github.com/rix4uni/favinfo/fingerprint"
+)
+
+// fakeCDNChecker reports every host as matched (or not) per its configured
+// verdict, without touching the network.
+type fakeCDNChecker struct {
+	matched bool
+}
+
+func (f fakeCDNChecker) Check(host string) (cdn.Result, error) {
+	if f.matched {
+		return cdn.Result{Matched: true, Provider: "Cloudflare", Type: "cdn"}, nil
+	}
+	return cdn.Result{Matched: false}, nil
+}
+
+func newTestFaviconServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><link rel="icon" href="/favicon.ico"></head></html>`))
+		case "/favicon.ico":
+			w.Header().Set("Content-Type", "image/x-icon")
+			w.Write([]byte("icon-bytes"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestProcessURLExcludeCDNSkipsMatchedTarget(t *testing.T) {
+	srv := newTestFaviconServer()
+	defer srv.Close()
+
+	out := processURL(srv.URL, srv.Client(), "test-agent", fingerprint.New(""), false, false, nil, fakeCDNChecker{matched: true}, true /* excludeCDN */, false, nil)
+
+	if strings.Contains(out, "favicon.ico") {
+		t.Errorf("output = %q, want no favicon fetch for a CDN-matched target with --exclude-cdn", out)
+	}
+}
+
+func TestProcessURLExcludeCDNFetchesUnmatchedTarget(t *testing.T) {
+	srv := newTestFaviconServer()
+	defer srv.Close()
+
+	out := processURL(srv.URL, srv.Client(), "test-agent", fingerprint.New(""), false, false, nil, fakeCDNChecker{matched: false}, true /* excludeCDN */, false, nil)
+
+	if !strings.Contains(out, "[") {
+		t.Errorf("output = %q, want a rendered hash list for a non-CDN target", out)
+	}
+}
+
+func TestProcessURLCDNOnlySkipsUnmatchedTarget(t *testing.T) {
+	srv := newTestFaviconServer()
+	defer srv.Close()
+
+	out := processURL(srv.URL, srv.Client(), "test-agent", fingerprint.New(""), false, false, nil, fakeCDNChecker{matched: false}, false, true /* cdnOnly */, nil)
+
+	if strings.Contains(out, "favicon.ico") {
+		t.Errorf("output = %q, want no favicon fetch for a non-CDN target with --cdn-only", out)
+	}
+}
+
+func TestProcessURLCDNOnlyFetchesMatchedTarget(t *testing.T) {
+	srv := newTestFaviconServer()
+	defer srv.Close()
+
+	out := processURL(srv.URL, srv.Client(), "test-agent", fingerprint.New(""), false, false, nil, fakeCDNChecker{matched: true}, false, true /* cdnOnly */, nil)
+
+	if !strings.Contains(out, "[") {
+		t.Errorf("output = %q, want a rendered hash list for a CDN-matched target with --cdn-only", out)
+	}
+}
+
+func TestProcessURLNilCDNCheckerNeverGates(t *testing.T) {
+	srv := newTestFaviconServer()
+	defer srv.Close()
+
+	// A nil cdnChecker (e.g. cdn.New() failed at startup) must behave like
+	// "not behind a CDN" and never abort the whole scan.
+	out := processURL(srv.URL, srv.Client(), "test-agent", fingerprint.New(""), false, false, nil, nil, true /* excludeCDN */, false, nil)
+
+	if !strings.Contains(out, "[") {
+		t.Errorf("output = %q, want a rendered hash list when cdnChecker is nil", out)
+	}
+}
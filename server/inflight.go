@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// errNoFavicon is returned by lookup when discovery finds no icon candidates.
+var errNoFavicon = errors.New("no favicon found")
+
+// inflightGroup coalesces concurrent calls for the same key into a single
+// execution of fn, the same way singleflight.Group does. It exists here
+// rather than as a dependency on golang.org/x/sync/singleflight to avoid
+// adding a new module just for this.
+type inflightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call tracks an in-flight (or just-finished) execution for one key.
+type call struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// do executes fn for key, or waits for and returns the result of an
+// already-in-flight call for the same key.
+func (g *inflightGroup) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+
+	c := new(call)
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.val, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return c.val, c.err
+}
+
+// writeJSON marshals v as indented JSON and writes it to w.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
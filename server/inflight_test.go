@@ -0,0 +1,83 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInflightGroupCoalescesConcurrentCalls(t *testing.T) {
+	var g inflightGroup
+	var calls int32
+	release := make(chan struct{})
+
+	const n = 10
+	var wg sync.WaitGroup
+	results := make([]interface{}, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := g.do("same-key", func() (interface{}, error) {
+				<-release
+				atomic.AddInt32(&calls, 1)
+				return "result", nil
+			})
+			if err != nil {
+				t.Errorf("do returned error: %v", err)
+			}
+			results[i] = v
+		}(i)
+	}
+
+	// Give every goroutine a chance to join the same in-flight call before
+	// letting the single execution proceed.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("fn called %d times, want 1", calls)
+	}
+	for i, v := range results {
+		if v != "result" {
+			t.Errorf("results[%d] = %v, want %q", i, v, "result")
+		}
+	}
+}
+
+func TestInflightGroupSeparatesDifferentKeys(t *testing.T) {
+	var g inflightGroup
+	var calls int32
+
+	g.do("a", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, nil
+	})
+	g.do("b", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, nil
+	})
+
+	if calls != 2 {
+		t.Errorf("fn called %d times across distinct keys, want 2", calls)
+	}
+}
+
+func TestInflightGroupRunsAgainAfterCompletion(t *testing.T) {
+	var g inflightGroup
+	var calls int32
+
+	for i := 0; i < 3; i++ {
+		g.do("same-key", func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, nil
+		})
+	}
+
+	if calls != 3 {
+		t.Errorf("fn called %d times across sequential calls, want 3", calls)
+	}
+}
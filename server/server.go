@@ -0,0 +1,212 @@
+// Package server implements the favinfo HTTP daemon mode ("favinfo serve"):
+// a small read-only API exposing the same discovery/fetch/hash pipeline as
+// the CLI over HTTP.
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	_ "embed"
+
+	"github.com/rix4uni/favinfo/cache"
+	"github.com/rix4uni/favinfo/cdn"
+	"github.com/rix4uni/favinfo/favicon"
+	"github.com/rix4uni/favinfo/fingerprint"
+)
+
+//go:embed fallback.png
+var fallbackPNG []byte
+
+// Config holds the dependencies a Server needs to answer requests.
+type Config struct {
+	Client        *http.Client
+	UserAgent     string
+	FingerprintDB *fingerprint.DB
+	Cache         *cache.Cache
+	CDNChecker    *cdn.Checker
+	ProbePaths    []string
+}
+
+// Server is the favinfo HTTP daemon. It implements http.Handler.
+type Server struct {
+	cfg Config
+
+	inflight inflightGroup
+}
+
+// New returns a Server ready to be passed to http.ListenAndServe.
+func New(cfg Config) *Server {
+	return &Server{cfg: cfg}
+}
+
+// ServeHTTP dispatches requests to the /hash, /icon, and /fallback handlers.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/hash":
+		s.handleHash(w, r)
+	case "/icon":
+		s.handleIcon(w, r)
+	case "/fallback":
+		s.handleFallback(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// lookupResult is what the in-flight group coalesces per target URL.
+type lookupResult struct {
+	processedInput string
+	icon           favicon.Icon
+	body           []byte
+	murmurHash     int32
+	md5Hash        string
+	sha256Hash     string
+}
+
+// lookup runs discovery + fetch + hash for target, coalescing concurrent
+// requests for the same target into a single upstream fetch.
+func (s *Server) lookup(target string) (lookupResult, error) {
+	v, err := s.inflight.do(target, func() (interface{}, error) {
+		req, err := http.NewRequest("GET", target, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", s.cfg.UserAgent)
+
+		processedInput := favicon.EnsureProtocol(target, s.cfg.Client)
+		icons, err := favicon.GetIcons(processedInput, s.cfg.Client, false, s.cfg.ProbePaths)
+		if err != nil {
+			return nil, err
+		}
+		if len(icons) == 0 {
+			return nil, errNoFavicon
+		}
+
+		body, murmurHash, md5Hash, sha256Hash, err := favicon.FetchAndHash(icons[0].URL, s.cfg.Client, s.cfg.Cache)
+		if err != nil {
+			return nil, err
+		}
+
+		return lookupResult{
+			processedInput: processedInput,
+			icon:           icons[0],
+			body:           body,
+			murmurHash:     murmurHash,
+			md5Hash:        md5Hash,
+			sha256Hash:     sha256Hash,
+		}, nil
+	})
+	if err != nil {
+		return lookupResult{}, err
+	}
+
+	return v.(lookupResult), nil
+}
+
+// handleHash serves GET /hash?url=... with the same ExtendedResult JSON as
+// the CLI's --json output.
+func (s *Server) handleHash(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+	if target == "" {
+		http.Error(w, "missing url parameter", http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.lookup(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	tech := "unknown"
+	if s.cfg.FingerprintDB != nil {
+		if techs := s.cfg.FingerprintDB.Lookup(res.murmurHash); len(techs) > 0 {
+			tech = strings.Join(techs, ", ")
+		}
+	}
+
+	// Resolve the target host and check it against known CDN/WAF/cloud
+	// provider ranges. A failed check (e.g. DNS resolution error) is treated
+	// the same as "not behind a CDN" rather than failing the request.
+	var cdnResult cdn.Result
+	if s.cfg.CDNChecker != nil {
+		if host := hostOf(res.processedInput); host != "" {
+			if r, err := s.cfg.CDNChecker.Check(host); err == nil {
+				cdnResult = r
+			}
+		}
+	}
+
+	faviconURL := res.icon.URL
+	isDataURI := strings.HasPrefix(faviconURL, "data:")
+	if isDataURI {
+		faviconURL = favicon.TruncateDataURI(faviconURL)
+	}
+
+	result := favicon.ExtendedResult{
+		Result: favicon.Result{
+			InputURL:   res.processedInput,
+			FaviconURL: faviconURL,
+			DataURI:    isDataURI,
+			MurmurHash: res.murmurHash,
+			MD5Hash:    res.md5Hash,
+			SHA256Hash: res.sha256Hash,
+			Technology: tech,
+			CDN:        cdnResult,
+		},
+		SearchQueries: favicon.GenerateSearchQueries(res.murmurHash, res.md5Hash),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, result)
+}
+
+// handleIcon serves GET /icon?url=..., proxying the raw favicon bytes with
+// Content-Type, ETag, and Cache-Control headers.
+func (s *Server) handleIcon(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+	if target == "" {
+		http.Error(w, "missing url parameter", http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.lookup(target)
+	if err != nil {
+		s.handleFallback(w, r)
+		return
+	}
+
+	contentType := ""
+	if s.cfg.Cache != nil {
+		if entry, ok := s.cfg.Cache.Get(res.icon.URL); ok {
+			contentType = entry.ContentType
+		}
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(res.body)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", strconv.Quote(res.sha256Hash))
+	w.Header().Set("Cache-Control", "max-age=86400")
+	w.Write(res.body)
+}
+
+// handleFallback serves GET /fallback: an embedded placeholder PNG used when
+// discovery fails.
+func (s *Server) handleFallback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "max-age=86400")
+	w.Write(fallbackPNG)
+}
+
+// hostOf returns the bare hostname (no port) of rawURL, or "" if it can't be parsed.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}